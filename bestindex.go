@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLite constraint operator codes (SQLITE_INDEX_CONSTRAINT_*). These are
+// part of the stable SQLite C ABI, not go-sqlite3 internals, so we define
+// them ourselves rather than depend on the driver exporting them.
+const (
+	sqliteConstraintEQ    = 2
+	sqliteConstraintGT    = 4
+	sqliteConstraintLE    = 8
+	sqliteConstraintLT    = 16
+	sqliteConstraintGE    = 32
+	sqliteConstraintLimit = 73
+)
+
+// Pseudo-column names used in pushedConstraint to carry a row cap through
+// idxStr. pushedLimitColumn stands in for a SQL LIMIT clause (no real
+// column index); hiddenLimitColumn is the declared HIDDEN _limit column
+// a query can bind directly (`WHERE _limit = 50`).
+const (
+	pushedLimitColumn = "__limit__"
+	hiddenLimitColumn = "_limit"
+)
+
+// pushedConstraint is one WHERE term InfiniDB has decided to honor itself
+// (Used=true) instead of leaving it to SQLite's default full-scan
+// filtering.
+type pushedConstraint struct {
+	Column string `json:"column"`
+	Op     string `json:"op"`
+}
+
+type pushedOrder struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc"`
+}
+
+// pushedIndex is what BestIndex encodes into idxStr and Filter decodes
+// back out. Constraint values themselves aren't known until Filter
+// (SQLite binds them as argv), so only shape (which column, which
+// operator, in what order) travels through idxStr.
+type pushedIndex struct {
+	Constraints []pushedConstraint `json:"constraints,omitempty"`
+	OrderBy     []pushedOrder      `json:"order_by,omitempty"`
+}
+
+func constraintOpName(op sqlite3.Op) (string, bool) {
+	switch op {
+	case sqliteConstraintEQ:
+		return "=", true
+	case sqliteConstraintGT:
+		return ">", true
+	case sqliteConstraintLE:
+		return "<=", true
+	case sqliteConstraintLT:
+		return "<", true
+	case sqliteConstraintGE:
+		return ">=", true
+	}
+	return "", false
+}
+
+// BestIndex tells SQLite which WHERE constraints and which ORDER BY (and,
+// where the driver surfaces it, LIMIT) we intend to honor ourselves so it
+// doesn't re-filter/re-sort everything after we hand back the full table.
+// The decision is encoded into IdxStr; Filter decodes it and does the
+// actual work.
+func (vt *InfiniTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
+	used := make([]bool, len(cst))
+	idx := pushedIndex{}
+
+	for i, c := range cst {
+		if !c.Usable {
+			continue
+		}
+		if c.Column == -1 && c.Op == sqliteConstraintLimit {
+			idx.Constraints = append(idx.Constraints, pushedConstraint{Column: pushedLimitColumn, Op: "limit"})
+			used[i] = true
+			continue
+		}
+		if c.Column == len(vt.declaredColumns) && c.Op == sqliteConstraintEQ {
+			// The hidden _limit column, e.g. `WHERE _limit = 50`.
+			idx.Constraints = append(idx.Constraints, pushedConstraint{Column: hiddenLimitColumn, Op: "="})
+			used[i] = true
+			continue
+		}
+		if c.Column < 0 || c.Column >= len(vt.declaredColumns) {
+			continue
+		}
+		opName, ok := constraintOpName(c.Op)
+		if !ok {
+			continue
+		}
+		idx.Constraints = append(idx.Constraints, pushedConstraint{
+			Column: vt.declaredColumns[c.Column].Name,
+			Op:     opName,
+		})
+		used[i] = true
+	}
+
+	for _, o := range ob {
+		if o.Column < 0 || o.Column >= len(vt.declaredColumns) {
+			continue
+		}
+		idx.OrderBy = append(idx.OrderBy, pushedOrder{
+			Column: vt.declaredColumns[o.Column].Name,
+			Desc:   o.Desc,
+		})
+	}
+
+	idxStr := ""
+	if len(idx.Constraints) > 0 || len(idx.OrderBy) > 0 {
+		encoded, err := json.Marshal(idx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode pushed-down index info: %w", err)
+		}
+		idxStr = string(encoded)
+	}
+
+	return &sqlite3.IndexResult{
+		Used:           used,
+		IdxStr:         idxStr,
+		AlreadyOrdered: len(idx.OrderBy) > 0,
+	}, nil
+}
+
+// Filter decodes the pushed-down constraints/order/limit that BestIndex
+// chose. When all the data is already in hand (a cache hit), it applies
+// them directly: a post-filter, a sort, and a limit. When rows are still
+// streaming in (see stream.go) and there's no ORDER BY to force full
+// materialization, the filter/limit are instead stashed on the cursor and
+// applied lazily as each row arrives. Constraint values arrive positionally
+// in vals, matching the order their pushedConstraint entries were appended
+// in BestIndex.
+func (cur *InfiniCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	cur.pos = 0
+
+	var idx pushedIndex
+	if idxStr != "" {
+		if err := json.Unmarshal([]byte(idxStr), &idx); err != nil {
+			return fmt.Errorf("failed to decode pushed-down index info: %w", err)
+		}
+	}
+
+	limit := int64(-1)
+	var rowConstraints []pushedConstraint
+	var rowVals []interface{}
+	if len(idx.Constraints) == len(vals) {
+		for i, c := range idx.Constraints {
+			if c.Column == pushedLimitColumn || c.Column == hiddenLimitColumn {
+				if n, ok := toFloat(vals[i]); ok && (limit < 0 || int64(n) < limit) {
+					limit = int64(n)
+				}
+				continue
+			}
+			rowConstraints = append(rowConstraints, c)
+			rowVals = append(rowVals, vals[i])
+		}
+	}
+	// If constraint/value counts disagree we can't safely pair them up;
+	// since Used=true told SQLite not to re-check these itself, fall back
+	// to returning everything rather than silently dropping rows.
+
+	if cur.stream != nil && len(idx.OrderBy) == 0 {
+		cur.rowConstraints = rowConstraints
+		cur.rowVals = rowVals
+		cur.limit = limit
+		cur.data = nil
+		return nil
+	}
+
+	if cur.stream != nil {
+		// An ORDER BY means we need every row before we can sort.
+		cur.drainStream()
+	}
+
+	filtered := cur.fullData
+	if len(rowConstraints) > 0 {
+		filtered = make([]map[string]interface{}, 0, len(cur.fullData))
+		for _, row := range cur.fullData {
+			if rowMatchesConstraints(row, rowConstraints, rowVals) {
+				filtered = append(filtered, row)
+			}
+		}
+	}
+
+	if len(idx.OrderBy) > 0 {
+		filtered = sortRows(filtered, idx.OrderBy)
+	}
+
+	if limit >= 0 && int64(len(filtered)) > limit {
+		filtered = filtered[:limit]
+	}
+
+	cur.data = filtered
+	return nil
+}
+
+func rowMatchesConstraints(row map[string]interface{}, constraints []pushedConstraint, vals []interface{}) bool {
+	for i, c := range constraints {
+		if !compareValues(row[c.Column], c.Op, vals[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareValues(rowVal interface{}, op string, queryVal interface{}) bool {
+	if rf, rOk := toFloat(rowVal); rOk {
+		if qf, qOk := toFloat(queryVal); qOk {
+			switch op {
+			case "=":
+				return rf == qf
+			case ">":
+				return rf > qf
+			case ">=":
+				return rf >= qf
+			case "<":
+				return rf < qf
+			case "<=":
+				return rf <= qf
+			}
+			return false
+		}
+	}
+
+	rs, qs := toComparableString(rowVal), toComparableString(queryVal)
+	switch op {
+	case "=":
+		return rs == qs
+	case ">":
+		return rs > qs
+	case ">=":
+		return rs >= qs
+	case "<":
+		return rs < qs
+	case "<=":
+		return rs <= qs
+	}
+	return false
+}
+
+func sortRows(rows []map[string]interface{}, orderBy []pushedOrder) []map[string]interface{} {
+	sorted := make([]map[string]interface{}, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, ob := range orderBy {
+			cmp := compareOrder(sorted[i][ob.Column], sorted[j][ob.Column])
+			if cmp == 0 {
+				continue
+			}
+			if ob.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return sorted
+}
+
+func compareOrder(a, b interface{}) int {
+	if af, aOk := toFloat(a); aOk {
+		if bf, bOk := toFloat(b); bOk {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	as, bs := toComparableString(a), toComparableString(b)
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func toComparableString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprintf("%v", s)
+	}
+}
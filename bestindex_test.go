@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestConstraintOpName(t *testing.T) {
+	cases := []struct {
+		op   uint8
+		want string
+		ok   bool
+	}{
+		{sqliteConstraintEQ, "=", true},
+		{sqliteConstraintGT, ">", true},
+		{sqliteConstraintLE, "<=", true},
+		{sqliteConstraintLT, "<", true},
+		{sqliteConstraintGE, ">=", true},
+		{99, "", false},
+	}
+	for _, c := range cases {
+		got, ok := constraintOpName(sqlite3.Op(c.op))
+		if ok != c.ok || got != c.want {
+			t.Errorf("constraintOpName(%d) = (%q, %v), want (%q, %v)", c.op, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestRowMatchesConstraints(t *testing.T) {
+	row := map[string]interface{}{"age": float64(30), "name": "alice"}
+
+	if !rowMatchesConstraints(row, []pushedConstraint{{Column: "age", Op: ">="}}, []interface{}{float64(18)}) {
+		t.Fatalf("expected age>=18 to match")
+	}
+	if rowMatchesConstraints(row, []pushedConstraint{{Column: "age", Op: "<"}}, []interface{}{float64(18)}) {
+		t.Fatalf("expected age<18 not to match")
+	}
+	if !rowMatchesConstraints(row, []pushedConstraint{{Column: "name", Op: "="}}, []interface{}{"alice"}) {
+		t.Fatalf("expected name=alice to match")
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"age": float64(30)},
+		{"age": float64(10)},
+		{"age": float64(20)},
+	}
+	sorted := sortRows(rows, []pushedOrder{{Column: "age"}})
+	ages := []float64{}
+	for _, r := range sorted {
+		ages = append(ages, r["age"].(float64))
+	}
+	want := []float64{10, 20, 30}
+	for i := range want {
+		if ages[i] != want[i] {
+			t.Fatalf("sortRows ascending = %v, want %v", ages, want)
+		}
+	}
+
+	descSorted := sortRows(rows, []pushedOrder{{Column: "age", Desc: true}})
+	if descSorted[0]["age"].(float64) != 30 {
+		t.Fatalf("sortRows descending did not put largest value first: %v", descSorted)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const cacheSchemaDDL = `
+CREATE TABLE IF NOT EXISTS schemas (
+	table_name TEXT NOT NULL,
+	prompt_hash TEXT NOT NULL,
+	model TEXT NOT NULL,
+	table_desc TEXT NOT NULL,
+	columns_json TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (table_name, prompt_hash, model)
+);
+
+CREATE TABLE IF NOT EXISTS rows (
+	table_name TEXT NOT NULL,
+	prompt_hash TEXT NOT NULL,
+	model TEXT NOT NULL,
+	rowid INTEGER NOT NULL,
+	json TEXT NOT NULL,
+	PRIMARY KEY (table_name, prompt_hash, model, rowid)
+);
+
+CREATE TABLE IF NOT EXISTS embeddings (
+	hash TEXT PRIMARY KEY,
+	text TEXT NOT NULL,
+	vector BLOB NOT NULL,
+	created_at TEXT NOT NULL
+);
+`
+
+// Cache is the persistent SQLite-backed catalog for generated schemas and
+// rows, keyed by (table_name, prompt_hash, model) so a changed table
+// description or target model naturally misses the old entry instead of
+// serving stale data. All access is additionally guarded by mu so
+// concurrent goroutines can share one Cache safely.
+type Cache struct {
+	db *sql.DB
+	mu sync.RWMutex
+}
+
+func hashPrompt(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// OpenCache opens (creating if necessary) the SQLite catalog database at
+// path and ensures its tables exist.
+func OpenCache(path string) (*Cache, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	if _, err := db.Exec(cacheSchemaDDL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize cache schema: %w", err)
+	}
+	return &Cache{db: db}, nil
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// LoadSchema looks up a previously generated column list for tableName
+// under the given prompt+model. It always returns the prompt's hash so
+// callers can reuse it when storing a freshly generated schema.
+func (c *Cache) LoadSchema(tableName, prompt, model string) (columns []Column, promptHash string, ok bool, err error) {
+	promptHash = hashPrompt(prompt)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var tableDesc, columnsJSON string
+	row := c.db.QueryRow(`SELECT table_desc, columns_json FROM schemas WHERE table_name = ? AND prompt_hash = ? AND model = ?`, tableName, promptHash, model)
+	if err := row.Scan(&tableDesc, &columnsJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, promptHash, false, nil
+		}
+		return nil, promptHash, false, err
+	}
+
+	if err := json.Unmarshal([]byte(columnsJSON), &columns); err != nil {
+		return nil, promptHash, false, fmt.Errorf("failed to decode cached columns: %w", err)
+	}
+	return columns, promptHash, true, nil
+}
+
+// LoadLatestSchema returns the most recently stored schema for tableName,
+// regardless of which prompt/model produced it. It's used to look up a
+// table referenced as a foreign key target from another table's
+// generation, where we only have the table's name, not its description.
+func (c *Cache) LoadLatestSchema(tableName string) (columns []Column, tableDesc, model string, ok bool, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var columnsJSON string
+	row := c.db.QueryRow(`SELECT table_desc, model, columns_json FROM schemas WHERE table_name = ? ORDER BY created_at DESC LIMIT 1`, tableName)
+	if err := row.Scan(&tableDesc, &model, &columnsJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", "", false, nil
+		}
+		return nil, "", "", false, err
+	}
+
+	if err := json.Unmarshal([]byte(columnsJSON), &columns); err != nil {
+		return nil, "", "", false, fmt.Errorf("failed to decode cached columns: %w", err)
+	}
+	return columns, tableDesc, model, true, nil
+}
+
+func (c *Cache) StoreSchema(tableName, tableDesc, promptHash, model string, columns []Column) error {
+	columnsJSON, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO schemas (table_name, prompt_hash, model, table_desc, columns_json, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		tableName, promptHash, model, tableDesc, string(columnsJSON), time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// LoadRows looks up previously generated rows for tableName under the
+// given prompt hash + model.
+func (c *Cache) LoadRows(tableName, promptHash, model string) ([]map[string]interface{}, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loadRowsLocked(tableName, promptHash, model)
+}
+
+func (c *Cache) loadRowsLocked(tableName, promptHash, model string) ([]map[string]interface{}, bool, error) {
+	rows, err := c.db.Query(`SELECT json FROM rows WHERE table_name = ? AND prompt_hash = ? AND model = ? ORDER BY rowid`, tableName, promptHash, model)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, false, err
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+			return nil, false, fmt.Errorf("failed to decode cached row: %w", err)
+		}
+		result = append(result, decoded)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(result) == 0 {
+		return nil, false, nil
+	}
+	return result, true, nil
+}
+
+// StoreRows replaces the cached row set for (tableName, promptHash,
+// model), assigning a stable identity (see rowIdentityField) to any row
+// that doesn't already carry one from an earlier StoreRows/MutateRows
+// call.
+func (c *Cache) StoreRows(tableName, promptHash, model string, dataRows []map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.storeRowsLocked(tableName, promptHash, model, dataRows)
+}
+
+func (c *Cache) storeRowsLocked(tableName, promptHash, model string, dataRows []map[string]interface{}) error {
+	assignRowIdentities(dataRows)
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM rows WHERE table_name = ? AND prompt_hash = ? AND model = ?`, tableName, promptHash, model); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO rows (table_name, prompt_hash, model, rowid, json) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for i, r := range dataRows {
+		encoded, err := json.Marshal(r)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(tableName, promptHash, model, i, string(encoded)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MutateRows atomically loads the cached rows for (tableName, promptHash,
+// model), lets fn transform them, and writes the result back, all under a
+// single held lock. DML (see update.go) uses this instead of a separate
+// LoadRows+StoreRows pair so it can't race a concurrent DML call or the
+// background row stream's own completion write (stream.go).
+func (c *Cache) MutateRows(tableName, promptHash, model string, fn func([]map[string]interface{}) ([]map[string]interface{}, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rows, _, err := c.loadRowsLocked(tableName, promptHash, model)
+	if err != nil {
+		return err
+	}
+	mutated, err := fn(rows)
+	if err != nil {
+		return err
+	}
+	return c.storeRowsLocked(tableName, promptHash, model, mutated)
+}
+
+// rowIdentityField is a key InfiniDB stores inside each row's own map,
+// alongside its declared columns, so DML can address "the same row"
+// reliably even after a WHERE-filtered or ORDER BY-sorted SELECT has
+// changed its scan position. It isn't part of the declared SQL schema.
+const rowIdentityField = "_infinidb_rowid"
+
+func rowIdentity(row map[string]interface{}) (int64, bool) {
+	v, ok := row[rowIdentityField]
+	if !ok {
+		return 0, false
+	}
+	return toInt64(v)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// assignRowIdentities gives every row missing rowIdentityField the next
+// unused identity, so callers that build rows by hand (stream.go,
+// joins.go) don't each need to manage an identity counter themselves.
+func assignRowIdentities(rows []map[string]interface{}) {
+	var next int64
+	for _, r := range rows {
+		if id, ok := rowIdentity(r); ok && id >= next {
+			next = id + 1
+		}
+	}
+	for _, r := range rows {
+		if _, ok := rowIdentity(r); !ok {
+			r[rowIdentityField] = next
+			next++
+		}
+	}
+}
+
+// LoadEmbedding looks up a previously computed embedding by the SHA-256
+// hash of the text it was computed from.
+func (c *Cache) LoadEmbedding(hash string) ([]float32, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var blob []byte
+	row := c.db.QueryRow(`SELECT vector FROM embeddings WHERE hash = ?`, hash)
+	if err := row.Scan(&blob); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	vec, err := decodeFloats(blob)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode cached embedding: %w", err)
+	}
+	return vec, true, nil
+}
+
+// StoreEmbedding caches vec under the SHA-256 hash of text, so the same
+// text embedded again (whether via infini_embed or a semantic column)
+// never needs another round trip to the embeddings endpoint.
+func (c *Cache) StoreEmbedding(hash, text string, vec []float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.db.Exec(
+		`INSERT OR REPLACE INTO embeddings (hash, text, vector, created_at) VALUES (?, ?, ?, ?)`,
+		hash, text, encodeFloats(vec), time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// Refresh drops every cached schema and row entry for tableName, forcing
+// the next Connect/Open to regenerate from scratch. It backs the
+// `PRAGMA infinidb_refresh('t')` REPL command.
+func (c *Cache) Refresh(tableName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM schemas WHERE table_name = ?`, tableName); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM rows WHERE table_name = ?`, tableName); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+var (
+	globalCache     *Cache
+	globalCacheOnce sync.Once
+	globalCacheErr  error
+)
+
+// cacheDir returns the directory the shared catalog database lives in:
+// $INFINIDB_CACHE_DIR if set (so tests can point it at a throwaway temp
+// dir), otherwise the repo-local ".cache" default.
+func cacheDir() string {
+	if dir := os.Getenv("INFINIDB_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return ".cache"
+}
+
+// getCache lazily opens the shared infinidb.sqlite catalog used by every
+// InfiniDB table in this process.
+func getCache() (*Cache, error) {
+	globalCacheOnce.Do(func() {
+		dir := cacheDir()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			globalCacheErr = fmt.Errorf("failed to create cache directory: %w", err)
+			return
+		}
+		globalCache, globalCacheErr = OpenCache(filepath.Join(dir, "infinidb.sqlite"))
+	})
+	return globalCache, globalCacheErr
+}
+
+var (
+	infiniRefreshPragma  = regexp.MustCompile(`(?i)^PRAGMA\s+infinidb_refresh\s*\(\s*'([^']+)'\s*\)\s*;?\s*$`)
+	infiniRowCountPragma = regexp.MustCompile(`(?i)^PRAGMA\s+infinidb_rowcount\s*=\s*(\d+)\s*;?\s*$`)
+)
+
+// handleInfiniPragma intercepts infinidb's own pragma extensions, which
+// SQLite's query engine has no way to dispatch to us, before input reaches
+// db.Query/db.Exec. handled is false for anything it doesn't recognize.
+func handleInfiniPragma(input string) (handled bool, err error) {
+	if m := infiniRefreshPragma.FindStringSubmatch(input); m != nil {
+		cache, err := getCache()
+		if err != nil {
+			return true, err
+		}
+		return true, cache.Refresh(m[1])
+	}
+
+	if m := infiniRowCountPragma.FindStringSubmatch(input); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return true, fmt.Errorf("invalid infinidb_rowcount: %s", m[1])
+		}
+		setRowCount(n)
+		fmt.Printf("infinidb_rowcount set to %d\n", n)
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheSchemaRoundTrip(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "infinidb.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	defer cache.Close()
+
+	cols := []Column{{Name: "id", Type: "INTEGER", Constraints: "PRIMARY KEY"}}
+	promptHash := hashPrompt("describe widgets")
+
+	if _, _, ok, err := cache.LoadSchema("widgets", "describe widgets", "mock"); err != nil {
+		t.Fatalf("LoadSchema returned error on empty cache: %v", err)
+	} else if ok {
+		t.Fatalf("expected no cached schema before StoreSchema")
+	}
+
+	if err := cache.StoreSchema("widgets", "a widget table", promptHash, "mock", cols); err != nil {
+		t.Fatalf("StoreSchema failed: %v", err)
+	}
+
+	got, gotHash, ok, err := cache.LoadSchema("widgets", "describe widgets", "mock")
+	if err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected cached schema after StoreSchema")
+	}
+	if gotHash != promptHash {
+		t.Fatalf("expected prompt hash %q, got %q", promptHash, gotHash)
+	}
+	if len(got) != 1 || got[0].Name != "id" {
+		t.Fatalf("unexpected cached columns: %+v", got)
+	}
+}
+
+func TestCacheRowsRoundTrip(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "infinidb.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	defer cache.Close()
+
+	promptHash := hashPrompt("rows for widgets")
+	rows := []map[string]interface{}{
+		{"id": float64(1), "name": "a"},
+		{"id": float64(2), "name": "b"},
+	}
+
+	if err := cache.StoreRows("widgets", promptHash, "mock", rows); err != nil {
+		t.Fatalf("StoreRows failed: %v", err)
+	}
+
+	got, ok, err := cache.LoadRows("widgets", promptHash, "mock")
+	if err != nil {
+		t.Fatalf("LoadRows failed: %v", err)
+	}
+	if !ok || len(got) != 2 {
+		t.Fatalf("expected 2 cached rows, got %+v", got)
+	}
+
+	if err := cache.Refresh("widgets"); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, ok, err := cache.LoadRows("widgets", promptHash, "mock"); err != nil {
+		t.Fatalf("LoadRows returned error after Refresh: %v", err)
+	} else if ok {
+		t.Fatalf("expected no cached rows after Refresh")
+	}
+}
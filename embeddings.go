@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// semanticEmbeddingColumn is the name of the extra BLOB column InfiniDB
+// declares on a table created with a `semantic=` option (see
+// parseSemanticOption), e.g. `SELECT title FROM docs ORDER BY
+// infini_similarity(embedding, infini_embed('climate policy')) DESC`.
+const semanticEmbeddingColumn = "embedding"
+
+// embeddingConstraint marks a declared column, in InfiniTable.declaredColumns,
+// as InfiniDB-managed embedding data rather than something the model
+// generated, so Column() knows to base64-decode it instead of passing the
+// stored string through as raw bytes.
+const embeddingConstraint = "EMBEDDING"
+
+// Embedder is implemented by Generator backends that can turn text into a
+// vector. Not every backend can (e.g. Anthropic has no embeddings
+// endpoint), so it's a separate, optional interface rather than a method
+// on Generator itself.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// Embed calls the OpenAI embeddings endpoint directly; there's no
+// response_format/structured-output step involved, unlike schema/data
+// generation.
+func (g *openAIGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	resp, err := g.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String(text)},
+		Model: openai.EmbeddingModelTextEmbedding3Small,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("embeddings response contained no data")
+	}
+
+	embedding := resp.Data[0].Embedding
+	vec := make([]float32, len(embedding))
+	for i, f := range embedding {
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
+// Embed returns a short deterministic vector derived from text's hash, so
+// the mock backend can exercise semantic columns offline.
+func (g *mockGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	seed := mockSeed(text)
+	vec := make([]float32, 8)
+	for i := range vec {
+		vec[i] = float32((seed+uint32(i))%997) / 997.0
+	}
+	return vec, nil
+}
+
+// parseSemanticOption recognizes a `semantic='column'` (or unquoted)
+// CREATE VIRTUAL TABLE argument and returns the column name it names.
+func parseSemanticOption(arg string) (string, bool) {
+	arg = strings.TrimSpace(arg)
+	const prefix = "semantic="
+	if !strings.HasPrefix(strings.ToLower(arg), prefix) {
+		return "", false
+	}
+	col := strings.TrimSpace(arg[len(prefix):])
+	col = strings.Trim(col, "'\"")
+	if col == "" {
+		return "", false
+	}
+	return col, true
+}
+
+// attachEmbedding fills in row[semanticEmbeddingColumn] for a freshly
+// generated row when vt was created with a `semantic=` option, embedding
+// the configured column's text and caching the result by its SHA-256 hash
+// so the same text is never sent to the embeddings endpoint twice.
+func attachEmbedding(ctx context.Context, vt *InfiniTable, row map[string]interface{}) {
+	if vt.semanticColumn == "" {
+		return
+	}
+	text, ok := row[vt.semanticColumn].(string)
+	if !ok || text == "" {
+		return
+	}
+
+	cache, err := getCache()
+	if err != nil {
+		fmt.Printf("Warning: embedding skipped for %s: %v\n", vt.tableName, err)
+		return
+	}
+
+	hash := hashPrompt(text)
+	vec, ok, err := cache.LoadEmbedding(hash)
+	if err != nil {
+		fmt.Printf("Warning: failed to read embedding cache: %v\n", err)
+	}
+	if !ok {
+		genCfg, err := LoadGeneratorConfig()
+		if err != nil {
+			fmt.Printf("Warning: embedding skipped for %s: %v\n", vt.tableName, err)
+			return
+		}
+		gen, err := NewGenerator(genCfg)
+		if err != nil {
+			fmt.Printf("Warning: embedding skipped for %s: %v\n", vt.tableName, err)
+			return
+		}
+		embedder, supported := gen.(Embedder)
+		if !supported {
+			fmt.Printf("Warning: provider %q does not support embeddings; %s.%s left empty\n", genCfg.Provider, vt.tableName, semanticEmbeddingColumn)
+			return
+		}
+		vec, err = embedder.Embed(ctx, text)
+		if err != nil {
+			fmt.Printf("Warning: embedding failed for %s: %v\n", vt.tableName, err)
+			return
+		}
+		if err := cache.StoreEmbedding(hash, text, vec); err != nil {
+			fmt.Printf("Warning: failed to cache embedding: %v\n", err)
+		}
+	}
+
+	row[semanticEmbeddingColumn] = base64.StdEncoding.EncodeToString(encodeFloats(vec))
+}
+
+// encodeFloats/decodeFloats serialize a []float32 vector to/from the raw
+// little-endian byte layout used both for the BLOB infini_embed returns
+// and for the value stored in the row cache (there, additionally
+// base64-encoded, since the cache stores rows as JSON).
+func encodeFloats(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeFloats(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid embedding blob length %d", len(buf))
+	}
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vec, nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	var dot, normA, normB float64
+	for i := 0; i < n; i++ {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// infiniEmbed backs the infini_embed(text) SQL function: it's the cache
+// lookup, the Embedder call on a miss, and the cache write, all in the raw
+// (non-base64) byte layout SQL BLOB values use.
+func infiniEmbed(text string) ([]byte, error) {
+	cache, err := getCache()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashPrompt(text)
+	if vec, ok, err := cache.LoadEmbedding(hash); err != nil {
+		return nil, err
+	} else if ok {
+		return encodeFloats(vec), nil
+	}
+
+	genCfg, err := LoadGeneratorConfig()
+	if err != nil {
+		return nil, err
+	}
+	gen, err := NewGenerator(genCfg)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := gen.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("provider %q does not support embeddings", genCfg.Provider)
+	}
+
+	vec, err := embedder.Embed(context.Background(), text)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	if err := cache.StoreEmbedding(hash, text, vec); err != nil {
+		fmt.Printf("Warning: failed to cache embedding: %v\n", err)
+	}
+	return encodeFloats(vec), nil
+}
+
+// infiniSimilarity backs the infini_similarity(a, b) SQL function: cosine
+// similarity between two embedding BLOBs, whether they came from
+// infini_embed or from a semantic column's stored embedding.
+func infiniSimilarity(a, b []byte) (float64, error) {
+	va, err := decodeFloats(a)
+	if err != nil {
+		return 0, fmt.Errorf("invalid embedding argument: %w", err)
+	}
+	vb, err := decodeFloats(b)
+	if err != nil {
+		return 0, fmt.Errorf("invalid embedding argument: %w", err)
+	}
+	return cosineSimilarity(va, vb), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseSemanticOption(t *testing.T) {
+	col, ok := parseSemanticOption("semantic='body'")
+	if !ok || col != "body" {
+		t.Fatalf("parseSemanticOption(semantic='body') = (%q, %v), want (%q, %v)", col, ok, "body", true)
+	}
+
+	if _, ok := parseSemanticOption("model='gpt-4'"); ok {
+		t.Fatalf("expected parseSemanticOption to reject an unrelated option")
+	}
+}
+
+func TestEncodeDecodeFloatsRoundTrip(t *testing.T) {
+	vec := []float32{0.5, -1.25, 3}
+	decoded, err := decodeFloats(encodeFloats(vec))
+	if err != nil {
+		t.Fatalf("decodeFloats failed: %v", err)
+	}
+	if len(decoded) != len(vec) {
+		t.Fatalf("decodeFloats returned %d values, want %d", len(decoded), len(vec))
+	}
+	for i := range vec {
+		if decoded[i] != vec[i] {
+			t.Fatalf("decodeFloats[%d] = %v, want %v", i, decoded[i], vec[i])
+		}
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float32{1, 0}, []float32{1, 0}); got != 1 {
+		t.Fatalf("cosineSimilarity of identical vectors = %v, want 1", got)
+	}
+	if got := cosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Fatalf("cosineSimilarity of orthogonal vectors = %v, want 0", got)
+	}
+}
+
+func TestInfiniEmbedAndSimilarityWithMockProvider(t *testing.T) {
+	t.Setenv("INFINIDB_PROVIDER", "mock")
+
+	a, err := infiniEmbed("climate policy")
+	if err != nil {
+		t.Fatalf("infiniEmbed failed: %v", err)
+	}
+	b, err := infiniEmbed("climate policy")
+	if err != nil {
+		t.Fatalf("infiniEmbed failed: %v", err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("expected infiniEmbed to be deterministic for the same text")
+	}
+
+	sim, err := infiniSimilarity(a, a)
+	if err != nil {
+		t.Fatalf("infiniSimilarity failed: %v", err)
+	}
+	if sim < 0.999 {
+		t.Fatalf("expected a vector's similarity with itself to be ~1, got %v", sim)
+	}
+}
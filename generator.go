@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Generator is the abstraction over whatever model backend produces table
+// schemas and table data for InfiniDB. Every backend (hosted API or local)
+// implements this so InfiniDBModule/InfiniTable never talk to a specific
+// SDK directly.
+type Generator interface {
+	GenerateSchema(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error)
+	GenerateData(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error)
+}
+
+// GeneratorConfig controls which backend is used and how it's reached.
+// It is populated from environment variables and, optionally, a JSON
+// config file, with env vars taking precedence.
+type GeneratorConfig struct {
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	BaseURL     string  `json:"base_url"`
+	APIKey      string  `json:"api_key"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
+}
+
+const defaultConfigPath = "infinidb.json"
+
+// LoadGeneratorConfig reads infinidb.json (if present) and then overlays
+// INFINIDB_* environment variables on top of it. A missing config file is
+// not an error; missing provider/model fall back to the OpenAI defaults
+// this tool shipped with originally.
+func LoadGeneratorConfig() (GeneratorConfig, error) {
+	cfg := GeneratorConfig{
+		Provider:    "openai",
+		Model:       string(openai.ChatModelGPT4o2024_08_06),
+		Temperature: 0,
+		MaxTokens:   0,
+	}
+
+	if data, err := os.ReadFile(defaultConfigPath); err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse %s: %w", defaultConfigPath, err)
+		}
+	}
+
+	if v := os.Getenv("INFINIDB_PROVIDER"); v != "" {
+		cfg.Provider = v
+	}
+	if v := os.Getenv("INFINIDB_MODEL"); v != "" {
+		cfg.Model = v
+	}
+	if v := os.Getenv("INFINIDB_BASE_URL"); v != "" {
+		cfg.BaseURL = v
+	}
+	if v := os.Getenv("INFINIDB_API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+	if v := os.Getenv("INFINIDB_TEMPERATURE"); v != "" {
+		var t float64
+		if _, err := fmt.Sscanf(v, "%f", &t); err == nil {
+			cfg.Temperature = t
+		}
+	}
+	if v := os.Getenv("INFINIDB_MAX_TOKENS"); v != "" {
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil {
+			cfg.MaxTokens = n
+		}
+	}
+
+	return cfg, nil
+}
+
+// NewGenerator builds the Generator for cfg.Provider. Unknown providers
+// are a configuration error rather than a silent fallback.
+func NewGenerator(cfg GeneratorConfig) (Generator, error) {
+	switch cfg.Provider {
+	case "", "openai":
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY not set")
+		}
+		opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+		if cfg.BaseURL != "" {
+			opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+		}
+		return &openAIGenerator{client: openai.NewClient(opts...), model: cfg.Model}, nil
+	case "azure":
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("AZURE_OPENAI_API_KEY")
+		}
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("AZURE_OPENAI_API_KEY not set")
+		}
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("azure provider requires base_url (the Azure OpenAI endpoint)")
+		}
+		opts := []option.RequestOption{
+			option.WithAPIKey(cfg.APIKey),
+			option.WithBaseURL(cfg.BaseURL),
+			option.WithHeader("api-key", cfg.APIKey),
+		}
+		return &openAIGenerator{client: openai.NewClient(opts...), model: cfg.Model}, nil
+	case "anthropic":
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
+		}
+		model := cfg.Model
+		if model == "" {
+			model = "claude-sonnet-4-5"
+		}
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.anthropic.com"
+		}
+		return &anthropicGenerator{apiKey: apiKey, model: model, baseURL: baseURL, maxTokens: cfg.MaxTokens}, nil
+	case "ollama", "llamacpp":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		model := cfg.Model
+		if model == "" {
+			return nil, fmt.Errorf("%s provider requires a model name", cfg.Provider)
+		}
+		return &ollamaGenerator{baseURL: baseURL, model: model}, nil
+	case "mock":
+		return &mockGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator provider: %s", cfg.Provider)
+	}
+}
+
+// openAIGenerator talks to any OpenAI-compatible chat completions endpoint
+// (OpenAI itself, or Azure OpenAI once pointed at the right base URL).
+type openAIGenerator struct {
+	client openai.Client
+	model  string
+}
+
+func (g *openAIGenerator) complete(ctx context.Context, name, prompt string, jsonSchema interface{}) ([]byte, error) {
+	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:   name,
+		Schema: jsonSchema,
+		Strict: openai.Bool(true),
+	}
+
+	model := g.model
+	if model == "" {
+		model = string(openai.ChatModelGPT4o2024_08_06)
+	}
+
+	chat, err := g.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(prompt),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{JSONSchema: schemaParam},
+		},
+		Model: model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(chat.Choices[0].Message.Content), nil
+}
+
+func (g *openAIGenerator) GenerateSchema(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_schema", prompt, jsonSchema)
+}
+
+func (g *openAIGenerator) GenerateData(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_data", prompt, jsonSchema)
+}
+
+// anthropicGenerator drives Claude's Messages API. Anthropic has no
+// response_format=json_schema knob, so structured output is obtained by
+// forcing a single tool call whose input schema is the one we were given.
+type anthropicGenerator struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	maxTokens int
+}
+
+func (g *anthropicGenerator) complete(ctx context.Context, toolName, prompt string, jsonSchema interface{}) ([]byte, error) {
+	maxTokens := g.maxTokens
+	if maxTokens == 0 {
+		maxTokens = 4096
+	}
+
+	reqBody := map[string]interface{}{
+		"model":      g.model,
+		"max_tokens": maxTokens,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"tools": []map[string]interface{}{
+			{
+				"name":         toolName,
+				"description":  "Return the requested data as structured JSON",
+				"input_schema": jsonSchema,
+			},
+		},
+		"tool_choice": map[string]string{"type": "tool", "name": toolName},
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode anthropic request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("x-api-key", g.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("anthropic request failed: %s: %s", resp.Status, string(respBody))
+	}
+
+	var parsed struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+	for _, block := range parsed.Content {
+		if block.Type == "tool_use" {
+			return block.Input, nil
+		}
+	}
+	return nil, fmt.Errorf("anthropic response contained no tool_use block")
+}
+
+func (g *anthropicGenerator) GenerateSchema(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_schema", prompt, jsonSchema)
+}
+
+func (g *anthropicGenerator) GenerateData(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_data", prompt, jsonSchema)
+}
+
+// ollamaGenerator talks to a local Ollama/llama.cpp server through its
+// OpenAI-compatible /v1/chat/completions endpoint, which supports
+// response_format=json_schema the same way OpenAI does.
+type ollamaGenerator struct {
+	baseURL string
+	model   string
+}
+
+func (g *ollamaGenerator) complete(ctx context.Context, name, prompt string, jsonSchema interface{}) ([]byte, error) {
+	reqBody := map[string]interface{}{
+		"model": g.model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"response_format": map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   name,
+				"schema": jsonSchema,
+				"strict": true,
+			},
+		},
+		"stream": false,
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+
+	client := http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama request failed: %s: %s", resp.Status, string(respBody))
+	}
+
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse ollama response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, fmt.Errorf("ollama response contained no choices")
+	}
+	return []byte(parsed.Choices[0].Message.Content), nil
+}
+
+func (g *ollamaGenerator) GenerateSchema(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_schema", prompt, jsonSchema)
+}
+
+func (g *ollamaGenerator) GenerateData(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	return g.complete(ctx, "table_data", prompt, jsonSchema)
+}
+
+// mockGenerator is a deterministic, offline backend for tests and CI: it
+// never makes a network call, and its output only depends on the prompt
+// text, so the same table name/description always yields the same schema
+// and rows.
+type mockGenerator struct{}
+
+func mockSeed(prompt string) uint32 {
+	sum := sha256.Sum256([]byte(prompt))
+	var seed uint32
+	for _, b := range sum[:4] {
+		seed = seed<<8 | uint32(b)
+	}
+	return seed
+}
+
+func (g *mockGenerator) GenerateSchema(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	seed := mockSeed(prompt)
+	schema := TableSchema{
+		Columns: []Column{
+			{Name: "id", Type: "INTEGER", Constraints: "PRIMARY KEY", Description: "Row identifier"},
+			{Name: "name", Type: "TEXT", Description: "Name"},
+			{Name: "value", Type: "REAL", Description: fmt.Sprintf("Deterministic mock value (seed %d)", seed)},
+		},
+	}
+	return json.Marshal(schema)
+}
+
+func (g *mockGenerator) GenerateData(ctx context.Context, prompt string, jsonSchema interface{}) ([]byte, error) {
+	seed := mockSeed(prompt)
+	rows := make([]map[string]interface{}, 0, 5)
+	for i := 0; i < 5; i++ {
+		rows = append(rows, map[string]interface{}{
+			"id":    i + 1,
+			"name":  fmt.Sprintf("mock-row-%d", i+1),
+			"value": float64((seed+uint32(i))%1000) / 10.0,
+		})
+	}
+	return json.Marshal(map[string]interface{}{"rows": rows})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ForeignKeyConstraint tells the data-generation prompt that a column
+// must be populated from an existing parent table's primary key values,
+// so joins across two infinidb tables actually line up.
+type ForeignKeyConstraint struct {
+	Column           string        `json:"column"`
+	ReferencedTable  string        `json:"referenced_table"`
+	ReferencedColumn string        `json:"referenced_column"`
+	AllowedValues    []interface{} `json:"allowed_values"`
+}
+
+var (
+	fkGenerationMu sync.Mutex
+	fkInProgress   = make(map[string]bool)
+)
+
+// resolveForeignKeys finds every References-tagged column on vt, makes
+// sure the table it points at has data (generating it first if needed),
+// and returns the allowed values the generator should draw from for each.
+func resolveForeignKeys(vt *InfiniTable) ([]ForeignKeyConstraint, error) {
+	var constraints []ForeignKeyConstraint
+
+	for _, col := range vt.columns {
+		if col.References == "" {
+			continue
+		}
+
+		refTable, refColumn, err := parseReference(col.References)
+		if err != nil {
+			fmt.Printf("Warning: column %s.%s has invalid references %q: %v\n", vt.tableName, col.Name, col.References, err)
+			continue
+		}
+
+		_, rows, err := ensureTableGenerated(refTable)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %q referenced by %s.%s: %w", refTable, vt.tableName, col.Name, err)
+		}
+
+		values := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			if v, ok := row[refColumn]; ok {
+				values = append(values, v)
+			}
+		}
+
+		constraints = append(constraints, ForeignKeyConstraint{
+			Column:           col.Name,
+			ReferencedTable:  refTable,
+			ReferencedColumn: refColumn,
+			AllowedValues:    values,
+		})
+	}
+
+	return constraints, nil
+}
+
+// parseReference splits "table(column)" into its parts.
+func parseReference(ref string) (table, column string, err error) {
+	open := strings.Index(ref, "(")
+	if open < 0 || !strings.HasSuffix(ref, ")") {
+		return "", "", fmt.Errorf("expected format table(column)")
+	}
+	table = strings.TrimSpace(ref[:open])
+	column = strings.TrimSpace(ref[open+1 : len(ref)-1])
+	if table == "" || column == "" {
+		return "", "", fmt.Errorf("expected format table(column)")
+	}
+	return table, column, nil
+}
+
+// ensureTableGenerated makes sure tableName's data exists in the cache,
+// generating it synchronously (recursively resolving its own foreign
+// keys first) if it doesn't. tableName must already have a schema on
+// record, i.e. it must have been created with CREATE VIRTUAL TABLE at
+// some point in this or a prior session.
+func ensureTableGenerated(tableName string) ([]Column, []map[string]interface{}, error) {
+	fkGenerationMu.Lock()
+	if fkInProgress[tableName] {
+		fkGenerationMu.Unlock()
+		return nil, nil, fmt.Errorf("circular foreign key reference involving table %q", tableName)
+	}
+	fkInProgress[tableName] = true
+	fkGenerationMu.Unlock()
+	defer func() {
+		fkGenerationMu.Lock()
+		delete(fkInProgress, tableName)
+		fkGenerationMu.Unlock()
+	}()
+
+	cache, err := getCache()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, tableDesc, model, ok, err := cache.LoadLatestSchema(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("referenced table %q has not been created yet", tableName)
+	}
+
+	vt := &InfiniTable{tableName: tableName, tableDesc: tableDesc, columns: columns, model: model}
+
+	prompt, err := renderPrompt("prompts/data_generation.txt", PromptData{TableName: tableName, TableDesc: tableDesc})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render data prompt: %w", err)
+	}
+	promptHash := hashPrompt(prompt)
+
+	if rows, ok, err := cache.LoadRows(tableName, promptHash, model); err != nil {
+		return nil, nil, fmt.Errorf("failed to read row cache: %w", err)
+	} else if ok {
+		return columns, rows, nil
+	}
+
+	fkConstraints, err := resolveForeignKeys(vt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := generateRowsSync(vt, cache, promptHash, fkConstraints)
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, rows, nil
+}
+
+// generateRowsSync does a single blocking generation call (no streaming),
+// used when a table's rows are needed as someone else's foreign key
+// source rather than as the direct target of a query.
+func generateRowsSync(vt *InfiniTable, cache *Cache, promptHash string, fkConstraints []ForeignKeyConstraint) ([]map[string]interface{}, error) {
+	genCfg, err := LoadGeneratorConfig()
+	if err != nil {
+		return nil, err
+	}
+	gen, err := NewGenerator(genCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := renderPrompt("prompts/data_generation.txt", PromptData{
+		TableName:   vt.tableName,
+		TableDesc:   vt.tableDesc,
+		ForeignKeys: fkConstraints,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render data prompt: %w", err)
+	}
+
+	raw, err := gen.GenerateData(context.Background(), prompt, makeDataSchema(vt.columns))
+	if err != nil {
+		return nil, fmt.Errorf("data generation failed: %w", err)
+	}
+
+	var resp struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse data JSON: %w", err)
+	}
+	if len(resp.Rows) == 0 {
+		return nil, fmt.Errorf("no data generated")
+	}
+
+	for _, row := range resp.Rows {
+		attachEmbedding(context.Background(), vt, row)
+	}
+
+	if err := cache.StoreRows(vt.tableName, promptHash, vt.model, resp.Rows); err != nil {
+		fmt.Printf("Warning: failed to write to cache: %v\n", err)
+	}
+	return resp.Rows, nil
+}
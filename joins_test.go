@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	table, column, err := parseReference("people(id)")
+	if err != nil {
+		t.Fatalf("parseReference returned error: %v", err)
+	}
+	if table != "people" || column != "id" {
+		t.Fatalf("parseReference(%q) = (%q, %q), want (%q, %q)", "people(id)", table, column, "people", "id")
+	}
+
+	if _, _, err := parseReference("people"); err == nil {
+		t.Fatalf("expected error for reference missing parens")
+	}
+}
+
+func TestResolveForeignKeysUsesParentValues(t *testing.T) {
+	t.Setenv("INFINIDB_PROVIDER", "mock")
+
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "infinidb.sqlite"))
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	defer cache.Close()
+
+	// resolveForeignKeys/ensureTableGenerated go through the process-global
+	// getCache() singleton rather than taking a *Cache directly, so force it
+	// open once (a no-op if some earlier test already did) and then swap in
+	// our throwaway cache.
+	getCache()
+	globalCache = cache
+
+	parentColumns := []Column{
+		{Name: "id", Type: "INTEGER", Constraints: "PRIMARY KEY"},
+		{Name: "name", Type: "TEXT"},
+	}
+	if err := cache.StoreSchema("people", "people in the org", "parent-hash", "mock", parentColumns); err != nil {
+		t.Fatalf("StoreSchema failed: %v", err)
+	}
+
+	child := &InfiniTable{
+		tableName: "orders",
+		tableDesc: "orders placed by people",
+		columns: []Column{
+			{Name: "id", Type: "INTEGER", Constraints: "PRIMARY KEY"},
+			{Name: "person_id", Type: "INTEGER", References: "people(id)"},
+		},
+		model: "mock",
+	}
+
+	constraints, err := resolveForeignKeys(child)
+	if err != nil {
+		t.Fatalf("resolveForeignKeys failed: %v", err)
+	}
+	if len(constraints) != 1 {
+		t.Fatalf("expected 1 foreign key constraint, got %d", len(constraints))
+	}
+	fk := constraints[0]
+	if fk.Column != "person_id" || fk.ReferencedTable != "people" || fk.ReferencedColumn != "id" {
+		t.Fatalf("unexpected constraint: %+v", fk)
+	}
+	if len(fk.AllowedValues) != 5 {
+		t.Fatalf("expected 5 allowed values from the mock generator's 5 rows, got %d", len(fk.AllowedValues))
+	}
+}
+
+func TestEnsureTableGeneratedRejectsCircularReference(t *testing.T) {
+	fkGenerationMu.Lock()
+	fkInProgress["a"] = true
+	fkGenerationMu.Unlock()
+	defer func() {
+		fkGenerationMu.Lock()
+		delete(fkInProgress, "a")
+		fkGenerationMu.Unlock()
+	}()
+
+	if _, _, err := ensureTableGenerated("a"); err == nil {
+		t.Fatalf("expected circular reference error")
+	}
+}
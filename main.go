@@ -4,17 +4,16 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"text/tabwriter"
 	"text/template"
 
 	"github.com/invopop/jsonschema"
 	"github.com/mattn/go-sqlite3"
-	"github.com/openai/openai-go"
 	"github.com/reeflective/readline"
 )
 
@@ -23,17 +22,28 @@ type Column struct {
 	Type        string `json:"type" jsonschema_description:"The SQLite type of the column" jsonschema:"enum=INTEGER,enum=TEXT,enum=REAL,enum=BLOB"`
 	Constraints string `json:"constraints" jsonschema_description:"SQL constraints for the column (e.g., PRIMARY KEY, UNIQUE)"`
 	Description string `json:"description" jsonschema_description:"A brief description of the column"`
+	References  string `json:"references" jsonschema_description:"If this column is a foreign key into another infinidb table, the referenced table and column formatted as table(column); empty otherwise"`
 }
 
 type TableSchema struct {
 	Columns []Column `json:"columns" jsonschema_description:"The list of columns for the table"`
 }
 
-var schemaCache = make(map[string][]Column)
-
 type PromptData struct {
 	TableName string
 	TableDesc string
+
+	// Populated for continuation requests issued by the streaming row
+	// generator (see stream.go); zero-valued for the schema prompt and
+	// for the first data-generation batch.
+	BatchSize  int
+	BatchIndex int
+	ExcludeIDs []string
+
+	// Populated when one or more columns reference another infinidb
+	// table (see joins.go), so the model can pick foreign key values that
+	// actually exist in the parent table.
+	ForeignKeys []ForeignKeyConstraint
 }
 
 func renderPrompt(templatePath string, data interface{}) (string, error) {
@@ -64,44 +74,45 @@ func (m *InfiniDBModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.
 	//fmt.Println("Creating table:", tableName)
 	//fmt.Println("Description:", tableDesc)
 
-	var columns []Column
-	if cached, ok := schemaCache[tableName]; ok {
-		columns = cached
-	} else {
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" {
-			return nil, fmt.Errorf("OPENAI_API_KEY not set")
-		}
-		client := openai.NewClient()
-		
-		schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-			Name:        "table_schema",
-			Description: openai.String("Schema definition for a SQLite table"),
-			Schema:      GenerateSchema[TableSchema](),
-			Strict:      openai.Bool(true),
+	semanticColumn := ""
+	for _, arg := range args[4:] {
+		if col, ok := parseSemanticOption(arg); ok {
+			semanticColumn = col
 		}
+	}
+
+	cache, err := getCache()
+	if err != nil {
+		return nil, err
+	}
 
-		prompt, err := renderPrompt("prompts/schema_generation.txt", PromptData{TableName: tableName, TableDesc: tableDesc})
+	genCfg, err := LoadGeneratorConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := renderPrompt("prompts/schema_generation.txt", PromptData{TableName: tableName, TableDesc: tableDesc})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render schema prompt: %w", err)
+	}
+
+	columns, promptHash, ok, err := cache.LoadSchema(tableName, prompt, genCfg.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema cache: %w", err)
+	}
+	if !ok {
+		gen, err := NewGenerator(genCfg)
 		if err != nil {
-			return nil, fmt.Errorf("failed to render schema prompt: %w", err)
+			return nil, err
 		}
 
-		chat, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-			Messages: []openai.ChatCompletionMessageParamUnion{
-				openai.UserMessage(prompt),
-			},
-			ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-				OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{JSONSchema: schemaParam},
-			},
-			Model: openai.ChatModelGPT4o2024_08_06,
-		})
-
+		raw, err := gen.GenerateSchema(context.Background(), prompt, GenerateSchema[TableSchema]())
 		if err != nil {
 			return nil, fmt.Errorf("schema generation failed: %w", err)
 		}
 
 		var tableSchema TableSchema
-		if err := json.Unmarshal([]byte(chat.Choices[0].Message.Content), &tableSchema); err != nil {
+		if err := json.Unmarshal(raw, &tableSchema); err != nil {
 			return nil, fmt.Errorf("failed to parse schema JSON: %w", err)
 		}
 		columns = tableSchema.Columns
@@ -123,25 +134,52 @@ func (m *InfiniDBModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.
 			}
 		}
 
-		schemaCache[tableName] = columns
+		if err := cache.StoreSchema(tableName, tableDesc, promptHash, genCfg.Model, columns); err != nil {
+			return nil, fmt.Errorf("failed to write schema cache: %w", err)
+		}
+	}
+
+	// declaredColumns is what actually gets declared to SQLite and what the
+	// cursor indexes Column() calls against; columns (the LLM-generated
+	// ones) is what data generation and foreign key resolution work with.
+	// They differ only when a `semantic=` option adds an embedding column
+	// InfiniDB fills in itself rather than asking the model to generate.
+	declaredColumns := columns
+	if semanticColumn != "" {
+		declaredColumns = append(declaredColumns, Column{
+			Name:        semanticEmbeddingColumn,
+			Type:        "BLOB",
+			Constraints: embeddingConstraint,
+			Description: fmt.Sprintf("Embedding vector for the %s column", semanticColumn),
+		})
 	}
 
 	// Build schema string
 	schemaParts := []string{}
-	for _, col := range columns {
+	for _, col := range declaredColumns {
 		part := fmt.Sprintf("%s %s", col.Name, col.Type)
-		if col.Constraints != "" {
+		if col.Constraints != "" && col.Constraints != embeddingConstraint {
 			part += " " + col.Constraints
 		}
 		schemaParts = append(schemaParts, part)
 	}
+	// A HIDDEN column so a query can cap streamed generation per-table,
+	// e.g. `SELECT * FROM t WHERE _limit = 50`.
+	schemaParts = append(schemaParts, hiddenLimitColumn+" INTEGER HIDDEN")
 	schema := "CREATE TABLE virtual_table (" + strings.Join(schemaParts, ", ") + ")"
 
 	if err := c.DeclareVTab(schema); err != nil {
 		return nil, err
 	}
 
-	return &InfiniTable{tableName: tableName, tableDesc: tableDesc, columns: columns}, nil
+	return &InfiniTable{
+		tableName:       tableName,
+		tableDesc:       tableDesc,
+		columns:         columns,
+		declaredColumns: declaredColumns,
+		model:           genCfg.Model,
+		semanticColumn:  semanticColumn,
+	}, nil
 }
 
 func (m *InfiniDBModule) DestroyModule() {}
@@ -150,113 +188,104 @@ type InfiniTable struct {
 	tableName string
 	tableDesc string
 	columns   []Column
-}
-
-func (vt *InfiniTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy) (*sqlite3.IndexResult, error) {
-	return &sqlite3.IndexResult{
-		Used: make([]bool, len(cst)),
-	}, nil
+	model     string
+
+	// declaredColumns is columns plus, when semanticColumn is set, the
+	// trailing synthetic embedding column. It's what's declared to SQLite
+	// and what cursor column indices (Column(), BestIndex) are relative
+	// to; columns alone is what data generation and FK resolution use.
+	declaredColumns []Column
+
+	// semanticColumn is the column named by a `semantic=` virtual table
+	// option, or "" if none was given. When set, every generated row gets
+	// an embedding of that column's text attached under the "embedding"
+	// column (see attachEmbedding).
+	semanticColumn string
 }
 
 func (vt *InfiniTable) Disconnect() error { return nil }
 func (vt *InfiniTable) Destroy() error    { return nil }
 
 func (vt *InfiniTable) Open() (sqlite3.VTabCursor, error) {
-	cacheDir := ".cache"
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		fmt.Printf("Warning: failed to create cache directory: %v\n", err)
-	}
-
-	cacheFile := filepath.Join(cacheDir, fmt.Sprintf("%s_data.json", vt.tableName))
-	if data, err := os.ReadFile(cacheFile); err == nil {
-		var rows []map[string]interface{}
-		if err := json.Unmarshal(data, &rows); err == nil {
-			fmt.Println("Loading data from cache for table:", vt.tableName)
-			return &InfiniCursor{tableName: vt.tableName, data: rows, pos: 0, columns: vt.columns}, nil
-		}
-	}
-
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY not set")
-	}
-	client := openai.NewClient()
-
-	dataSchema := makeDataSchema(vt.columns)
-	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        "table_data",
-		Description: openai.String("Generated data rows"),
-		Schema:      dataSchema,
-		Strict:      openai.Bool(true),
+	cache, err := getCache()
+	if err != nil {
+		return nil, err
 	}
 
 	prompt, err := renderPrompt("prompts/data_generation.txt", PromptData{TableName: vt.tableName, TableDesc: vt.tableDesc})
 	if err != nil {
 		return nil, fmt.Errorf("failed to render data prompt: %w", err)
 	}
+	promptHash := hashPrompt(prompt)
 
-	chat, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{JSONSchema: schemaParam},
-		},
-		Model: openai.ChatModelGPT4o2024_08_06,
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("data generation failed: %w", err)
-	}
-
-	// We need a struct to unmarshal the response which has a "rows" field
-	type DataResponse struct {
-		Rows []map[string]interface{} `json:"rows"`
-	}
-	var dataResp DataResponse
-	if err := json.Unmarshal([]byte(chat.Choices[0].Message.Content), &dataResp); err != nil {
-		return nil, fmt.Errorf("failed to parse data JSON: %w", err)
+	if rows, ok, err := cache.LoadRows(vt.tableName, promptHash, vt.model); err != nil {
+		return nil, fmt.Errorf("failed to read row cache: %w", err)
+	} else if ok {
+		fmt.Println("Loading data from cache for table:", vt.tableName)
+		return &InfiniCursor{tableName: vt.tableName, fullData: rows, data: rows, pos: 0, columns: vt.declaredColumns, limit: -1}, nil
 	}
 
-	if len(dataResp.Rows) == 0 {
-		return nil, fmt.Errorf("no data generated")
+	fkConstraints, err := resolveForeignKeys(vt)
+	if err != nil {
+		return nil, err
 	}
 
-	if jsonData, err := json.Marshal(dataResp.Rows); err == nil {
-		if err := os.WriteFile(cacheFile, jsonData, 0644); err != nil {
-			fmt.Printf("Warning: failed to write to cache: %v\n", err)
-		}
-	}
+	dataSchema := makeDataSchema(vt.columns)
+	max := getRowCount()
+	stream := startRowStream(vt, cache, promptHash, dataSchema, max, fkConstraints)
 
-	return &InfiniCursor{tableName: vt.tableName, data: dataResp.Rows, pos: 0, columns: vt.columns}, nil
+	return &InfiniCursor{tableName: vt.tableName, columns: vt.declaredColumns, stream: stream, limit: -1}, nil
 }
 
 type InfiniCursor struct {
 	tableName string
+	fullData  []map[string]interface{}
 	data      []map[string]interface{}
 	pos       int
 	columns   []Column
+
+	// Streaming-mode state: stream is non-nil while rows are still being
+	// generated in the background; rowConstraints/rowVals/limit are the
+	// pushed-down filter and cap Filter decided on, applied lazily as rows
+	// arrive instead of over the (not yet complete) full data set.
+	stream         *rowStream
+	rowConstraints []pushedConstraint
+	rowVals        []interface{}
+	limit          int64
 }
 
-func (cur *InfiniCursor) Close() error { return nil }
-func (cur *InfiniCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
-	cur.pos = 0
+func (cur *InfiniCursor) Close() error {
+	if cur.stream != nil {
+		cur.stream.cancel()
+	}
 	return nil
 }
+
 func (cur *InfiniCursor) Next() error {
 	cur.pos += 1
 	return nil
 }
 
 func (cur *InfiniCursor) EOF() bool {
+	cur.fillUpTo(cur.pos + 1)
 	return cur.pos >= len(cur.data)
 }
 
 func (cur *InfiniCursor) Rowid() (int64, error) {
+	if cur.pos >= 0 && cur.pos < len(cur.data) {
+		if id, ok := rowIdentity(cur.data[cur.pos]); ok {
+			return id, nil
+		}
+	}
 	return int64(cur.pos), nil
 }
 
 func (cur *InfiniCursor) Column(c *sqlite3.SQLiteContext, col int) error {
+	if col == len(cur.columns) {
+		// The hidden _limit column is a query-time knob, not stored data.
+		c.ResultNull()
+		return nil
+	}
 	if cur.pos < 0 || cur.pos >= len(cur.data) || col < 0 || col >= len(cur.columns) {
 		return fmt.Errorf("invalid cursor position or column index")
 	}
@@ -292,7 +321,17 @@ func (cur *InfiniCursor) Column(c *sqlite3.SQLiteContext, col int) error {
 			return fmt.Errorf("type mismatch for %s: expected real", colName)
 		}
 	case "BLOB":
-		if v, ok := value.(string); ok {
+		if cur.columns[col].Constraints == embeddingConstraint {
+			v, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("type mismatch for %s: expected base64-encoded blob", colName)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return fmt.Errorf("invalid embedding encoding for %s: %w", colName, err)
+			}
+			c.ResultBlob(decoded)
+		} else if v, ok := value.(string); ok {
 			c.ResultBlob([]byte(v))
 		} else if v, ok := value.([]byte); ok {
 			c.ResultBlob(v)
@@ -359,7 +398,13 @@ func init() {
 	sql.Register("infinidb",
 		&sqlite3.SQLiteDriver{
 			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
-				return conn.CreateModule("infinidb", &InfiniDBModule{})
+				if err := conn.CreateModule("infinidb", &InfiniDBModule{}); err != nil {
+					return err
+				}
+				if err := conn.RegisterFunc("infini_embed", infiniEmbed, false); err != nil {
+					return err
+				}
+				return conn.RegisterFunc("infini_similarity", infiniSimilarity, true)
 			},
 		})
 }
@@ -390,6 +435,13 @@ func main() {
 			break
 		}
 
+		if handled, err := handleInfiniPragma(input); handled {
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+
 		upperInput := strings.ToUpper(input)
 		if strings.HasPrefix(upperInput, "SELECT") ||
 			strings.HasPrefix(upperInput, "PRAGMA") ||
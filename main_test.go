@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// TestInfiniDBMockProviderEndToEnd drives the full vtable lifecycle --
+// schema generation, row generation, and a SELECT -- through the mock
+// Generator backend, so the REPL/vtable flow can be exercised in CI
+// without an API key.
+func TestInfiniDBMockProviderEndToEnd(t *testing.T) {
+	t.Setenv("INFINIDB_PROVIDER", "mock")
+
+	db, err := sql.Open("infinidb", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open infinidb: %v", err)
+	}
+	defer db.Close()
+
+	const createSQL = `CREATE VIRTUAL TABLE mock_people USING infinidb('mock_people', 'test people for the mock backend')`
+	if _, err := db.Exec(createSQL); err != nil {
+		t.Fatalf("failed to create virtual table: %v", err)
+	}
+
+	rows, err := db.Query("SELECT id, name, value FROM mock_people ORDER BY id")
+	if err != nil {
+		t.Fatalf("failed to query virtual table: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id int64
+		var name string
+		var value float64
+		if err := rows.Scan(&id, &name, &value); err != nil {
+			t.Fatalf("failed to scan row: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s", id, name))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("row iteration error: %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("expected 5 deterministic mock rows, got %d: %v", len(got), got)
+	}
+	if got[0] != "1:mock-row-1" {
+		t.Fatalf("expected first row from the mock generator to be 1:mock-row-1, got %q", got[0])
+	}
+}
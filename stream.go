@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultRowCount is how many rows InfiniDB will generate for a table in
+// one query when nothing overrides it (SQL LIMIT, the hidden _limit
+// column, or PRAGMA infinidb_rowcount).
+const defaultRowCount = 1000
+
+// streamBatchSize is how many rows we ask the model for per streaming
+// continuation request.
+const streamBatchSize = 25
+
+var (
+	rowCountMu      sync.RWMutex
+	currentRowCount = defaultRowCount
+)
+
+func setRowCount(n int) {
+	rowCountMu.Lock()
+	defer rowCountMu.Unlock()
+	currentRowCount = n
+}
+
+func getRowCount() int {
+	rowCountMu.RLock()
+	defer rowCountMu.RUnlock()
+	return currentRowCount
+}
+
+// rowStream is a background row generator feeding an InfiniCursor. It
+// issues repeated "generate N more, avoiding these keys" requests until it
+// hits a natural EOF (the model returns nothing new), max rows, or ctx is
+// cancelled by the cursor being closed/satisfied early.
+type rowStream struct {
+	rows   chan map[string]interface{}
+	done   chan error
+	cancel context.CancelFunc
+}
+
+func startRowStream(vt *InfiniTable, cache *Cache, promptHash string, dataSchema interface{}, max int, fkConstraints []ForeignKeyConstraint) *rowStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &rowStream{
+		rows:   make(chan map[string]interface{}, streamBatchSize),
+		done:   make(chan error, 1),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(stream.rows)
+		stream.done <- runRowStream(ctx, vt, cache, promptHash, dataSchema, max, fkConstraints, stream.rows)
+		close(stream.done)
+	}()
+
+	return stream
+}
+
+func runRowStream(ctx context.Context, vt *InfiniTable, cache *Cache, promptHash string, dataSchema interface{}, max int, fkConstraints []ForeignKeyConstraint, out chan<- map[string]interface{}) error {
+	genCfg, err := LoadGeneratorConfig()
+	if err != nil {
+		return err
+	}
+	gen, err := NewGenerator(genCfg)
+	if err != nil {
+		return err
+	}
+
+	keyColumn := primaryKeyColumn(vt.columns)
+	seen := make(map[string]bool)
+	collected := 0
+	var allRows []map[string]interface{}
+
+	for batch := 0; collected < max; batch++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		want := streamBatchSize
+		if remaining := max - collected; remaining < want {
+			want = remaining
+		}
+
+		prompt, err := renderPrompt("prompts/data_generation.txt", PromptData{
+			TableName:   vt.tableName,
+			TableDesc:   vt.tableDesc,
+			BatchSize:   want,
+			BatchIndex:  batch,
+			ExcludeIDs:  sortedKeys(seen),
+			ForeignKeys: fkConstraints,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to render data prompt: %w", err)
+		}
+
+		raw, err := gen.GenerateData(ctx, prompt, dataSchema)
+		if err != nil {
+			return fmt.Errorf("data generation failed: %w", err)
+		}
+
+		var resp struct {
+			Rows []map[string]interface{} `json:"rows"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return fmt.Errorf("failed to parse data JSON: %w", err)
+		}
+
+		if len(resp.Rows) == 0 {
+			break // natural EOF: the model has nothing left to add
+		}
+
+		newInBatch := 0
+		for _, row := range resp.Rows {
+			key := rowKey(row, keyColumn)
+			if key != "" && seen[key] {
+				continue
+			}
+			if key != "" {
+				seen[key] = true
+			}
+
+			attachEmbedding(ctx, vt, row)
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return nil
+			}
+
+			allRows = append(allRows, row)
+			collected++
+			newInBatch++
+			if collected >= max {
+				break
+			}
+		}
+
+		fmt.Printf("infinidb: %s generated %d/%d rows\n", vt.tableName, collected, max)
+
+		if newInBatch == 0 {
+			break // the model kept returning rows we'd already seen
+		}
+	}
+
+	if cache != nil && len(allRows) > 0 {
+		if err := cache.StoreRows(vt.tableName, promptHash, vt.model, allRows); err != nil {
+			fmt.Printf("Warning: failed to write to cache: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// fillUpTo pulls rows off cur.stream (applying any lazily pushed-down
+// filter/limit) until cur.data has at least n entries or the stream is
+// exhausted/capped.
+func (cur *InfiniCursor) fillUpTo(n int) {
+	if cur.stream == nil {
+		return
+	}
+	for len(cur.data) < n {
+		if cur.limit >= 0 && int64(len(cur.data)) >= cur.limit {
+			cur.stream.cancel()
+			cur.stream = nil
+			return
+		}
+
+		row, ok := <-cur.stream.rows
+		if !ok {
+			if err := <-cur.stream.done; err != nil {
+				fmt.Printf("Warning: row generation for %s stopped early: %v\n", cur.tableName, err)
+			}
+			cur.stream = nil
+			return
+		}
+
+		if len(cur.rowConstraints) > 0 && !rowMatchesConstraints(row, cur.rowConstraints, cur.rowVals) {
+			continue
+		}
+		cur.data = append(cur.data, row)
+		cur.fullData = append(cur.fullData, row)
+	}
+}
+
+// drainStream blocks until the whole stream has been consumed into
+// cur.fullData. Needed when an ORDER BY forces us to see every row before
+// we can sort.
+func (cur *InfiniCursor) drainStream() {
+	if cur.stream == nil {
+		return
+	}
+	for row := range cur.stream.rows {
+		cur.fullData = append(cur.fullData, row)
+	}
+	if err := <-cur.stream.done; err != nil {
+		fmt.Printf("Warning: row generation for %s stopped early: %v\n", cur.tableName, err)
+	}
+	cur.stream = nil
+}
+
+func primaryKeyColumn(columns []Column) string {
+	for _, c := range columns {
+		if strings.Contains(strings.ToUpper(c.Constraints), "PRIMARY KEY") {
+			return c.Name
+		}
+	}
+	if len(columns) > 0 {
+		return columns[0].Name
+	}
+	return ""
+}
+
+func rowKey(row map[string]interface{}, keyColumn string) string {
+	if keyColumn == "" {
+		return ""
+	}
+	v, ok := row[keyColumn]
+	if !ok {
+		return ""
+	}
+	return toComparableString(v)
+}
+
+func sortedKeys(seen map[string]bool) []string {
+	ids := make([]string, 0, len(seen))
+	for k := range seen {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestPrimaryKeyColumn(t *testing.T) {
+	cols := []Column{
+		{Name: "name", Type: "TEXT"},
+		{Name: "id", Type: "INTEGER", Constraints: "PRIMARY KEY"},
+	}
+	if got := primaryKeyColumn(cols); got != "id" {
+		t.Fatalf("primaryKeyColumn() = %q, want %q", got, "id")
+	}
+
+	noKey := []Column{{Name: "name", Type: "TEXT"}}
+	if got := primaryKeyColumn(noKey); got != "name" {
+		t.Fatalf("primaryKeyColumn() with no PRIMARY KEY = %q, want first column %q", got, "name")
+	}
+}
+
+func TestRowKey(t *testing.T) {
+	row := map[string]interface{}{"id": float64(7)}
+	if got := rowKey(row, "id"); got != "7" {
+		t.Fatalf("rowKey() = %q, want %q", got, "7")
+	}
+	if got := rowKey(row, ""); got != "" {
+		t.Fatalf("rowKey() with no key column = %q, want empty", got)
+	}
+	if got := rowKey(row, "missing"); got != "" {
+		t.Fatalf("rowKey() with missing column = %q, want empty", got)
+	}
+}
+
+func TestStartRowStreamCollectsUpToMax(t *testing.T) {
+	t.Setenv("INFINIDB_PROVIDER", "mock")
+
+	vt := &InfiniTable{tableName: "stream_people", tableDesc: "people", model: "mock"}
+	stream := startRowStream(vt, nil, "deadbeef", makeDataSchema(nil), 3)
+
+	var got []map[string]interface{}
+	for row := range stream.rows {
+		got = append(got, row)
+	}
+	if err := <-stream.done; err != nil {
+		t.Fatalf("row stream returned error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 streamed rows, got %d: %v", len(got), got)
+	}
+}
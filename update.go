@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Delete, Insert, and Update implement sqlite3.VTabUpdater, giving
+// InfiniDB tables DML support (INSERT/UPDATE/DELETE). The row set lives
+// in the same SQLite-backed cache Open() reads from; mutations here
+// rewrite that cache atomically via Cache.MutateRows, keyed the same way
+// (table name, data-generation prompt hash, model) Open() uses. Rows are
+// addressed by their own stable rowIdentityField rather than their
+// current scan position, so a WHERE-filtered or ORDER BY-sorted SELECT
+// can't make a DML statement touch the wrong row.
+func (vt *InfiniTable) Delete(rowid interface{}) error {
+	cache, promptHash, err := vt.rowCache()
+	if err != nil {
+		return err
+	}
+	id, ok := toInt64(rowid)
+	if !ok {
+		return fmt.Errorf("infinidb: invalid rowid")
+	}
+
+	return cache.MutateRows(vt.tableName, promptHash, vt.model, func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+		idx, ok := findRowByIdentity(rows, id)
+		if !ok {
+			return nil, fmt.Errorf("infinidb: no such row")
+		}
+		return append(rows[:idx], rows[idx+1:]...), nil
+	})
+}
+
+func (vt *InfiniTable) Insert(rowid interface{}, vals []interface{}) (int64, error) {
+	cache, promptHash, err := vt.rowCache()
+	if err != nil {
+		return 0, err
+	}
+
+	// vals is [col0, col1, ..., _limit]; the hidden _limit column never
+	// has stored data, so it's dropped before mapping onto the declared
+	// columns.
+	colVals := vals
+	if len(colVals) > len(vt.columns) {
+		colVals = colVals[:len(vt.columns)]
+	}
+
+	var newID int64
+	err = cache.MutateRows(vt.tableName, promptHash, vt.model, func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+		var row map[string]interface{}
+		if allNil(colVals) {
+			// INSERT INTO t DEFAULT VALUES: grow the table with one more
+			// LLM-generated row instead of a row of NULLs.
+			generated, err := vt.generateSingleRow()
+			if err != nil {
+				return nil, err
+			}
+			row = generated
+		} else {
+			row = make(map[string]interface{}, len(vt.columns))
+			for i, col := range vt.columns {
+				if i < len(colVals) {
+					row[col.Name] = colVals[i]
+				}
+			}
+		}
+		newID = nextRowIdentity(rows)
+		row[rowIdentityField] = newID
+		return append(rows, row), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+func (vt *InfiniTable) Update(rowid interface{}, vals []interface{}) error {
+	cache, promptHash, err := vt.rowCache()
+	if err != nil {
+		return err
+	}
+	id, ok := toInt64(rowid)
+	if !ok {
+		return fmt.Errorf("infinidb: invalid rowid")
+	}
+
+	colVals := vals
+	if len(colVals) > len(vt.columns) {
+		colVals = colVals[:len(vt.columns)]
+	}
+
+	return cache.MutateRows(vt.tableName, promptHash, vt.model, func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+		idx, ok := findRowByIdentity(rows, id)
+		if !ok {
+			return nil, fmt.Errorf("infinidb: no such row")
+		}
+		row := make(map[string]interface{}, len(vt.columns)+1)
+		for i, col := range vt.columns {
+			if i < len(colVals) {
+				row[col.Name] = colVals[i]
+			}
+		}
+		row[rowIdentityField] = id
+		rows[idx] = row
+		return rows, nil
+	})
+}
+
+// rowCache renders this table's data-generation prompt just far enough to
+// get its hash -- the same key Open()/stream.go use to address this
+// table's row set in the cache.
+func (vt *InfiniTable) rowCache() (*Cache, string, error) {
+	cache, err := getCache()
+	if err != nil {
+		return nil, "", err
+	}
+	prompt, err := renderPrompt("prompts/data_generation.txt", PromptData{TableName: vt.tableName, TableDesc: vt.tableDesc})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render data prompt: %w", err)
+	}
+	return cache, hashPrompt(prompt), nil
+}
+
+func findRowByIdentity(rows []map[string]interface{}, id int64) (int, bool) {
+	for i, r := range rows {
+		if rid, ok := rowIdentity(r); ok && rid == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func nextRowIdentity(rows []map[string]interface{}) int64 {
+	var next int64
+	for _, r := range rows {
+		if id, ok := rowIdentity(r); ok && id >= next {
+			next = id + 1
+		}
+	}
+	return next
+}
+
+func allNil(vals []interface{}) bool {
+	for _, v := range vals {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateSingleRow drives one schema-conditioned generation call for
+// `INSERT INTO t DEFAULT VALUES`, so users can grow a table interactively
+// one row at a time.
+func (vt *InfiniTable) generateSingleRow() (map[string]interface{}, error) {
+	genCfg, err := LoadGeneratorConfig()
+	if err != nil {
+		return nil, err
+	}
+	gen, err := NewGenerator(genCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := renderPrompt("prompts/single_row_generation.txt", PromptData{TableName: vt.tableName, TableDesc: vt.tableDesc})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render single-row prompt: %w", err)
+	}
+
+	raw, err := gen.GenerateData(context.Background(), prompt, makeDataSchema(vt.columns))
+	if err != nil {
+		return nil, fmt.Errorf("single-row generation failed: %w", err)
+	}
+
+	var resp struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse single-row JSON: %w", err)
+	}
+	if len(resp.Rows) == 0 {
+		return nil, fmt.Errorf("no row generated")
+	}
+	return resp.Rows[0], nil
+}
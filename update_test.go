@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRowIdentitySurvivesReorder(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "infinidb.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	defer cache.Close()
+
+	promptHash := hashPrompt("rows for widgets")
+	if err := cache.StoreRows("widgets", promptHash, "mock", []map[string]interface{}{
+		{"id": float64(1), "name": "a"},
+		{"id": float64(2), "name": "b"},
+		{"id": float64(3), "name": "c"},
+	}); err != nil {
+		t.Fatalf("StoreRows failed: %v", err)
+	}
+
+	rows, _, err := cache.LoadRows("widgets", promptHash, "mock")
+	if err != nil {
+		t.Fatalf("LoadRows failed: %v", err)
+	}
+
+	// Simulate a sorted SELECT that puts "b" first, mirroring what
+	// bestindex.go's sortRows does to the cursor's data slice.
+	reordered := []map[string]interface{}{rows[1], rows[0], rows[2]}
+	bID, ok := rowIdentity(reordered[0])
+	if !ok {
+		t.Fatalf("expected row %v to carry an identity", reordered[0])
+	}
+
+	if err := cache.MutateRows("widgets", promptHash, "mock", func(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+		idx, ok := findRowByIdentity(rows, bID)
+		if !ok {
+			t.Fatalf("findRowByIdentity could not find row with identity %d", bID)
+		}
+		rows[idx]["name"] = "b-updated"
+		return rows, nil
+	}); err != nil {
+		t.Fatalf("MutateRows failed: %v", err)
+	}
+
+	got, _, err := cache.LoadRows("widgets", promptHash, "mock")
+	if err != nil {
+		t.Fatalf("LoadRows failed: %v", err)
+	}
+	idx, ok := findRowByIdentity(got, bID)
+	if !ok {
+		t.Fatalf("row with identity %d missing after MutateRows", bID)
+	}
+	if got[idx]["name"] != "b-updated" {
+		t.Fatalf("expected row %d to be updated, got %+v", bID, got[idx])
+	}
+}
+
+func TestNextRowIdentitySkipsExisting(t *testing.T) {
+	rows := []map[string]interface{}{
+		{rowIdentityField: int64(0)},
+		{rowIdentityField: int64(1)},
+		{rowIdentityField: int64(4)},
+	}
+	if got := nextRowIdentity(rows); got != 5 {
+		t.Fatalf("nextRowIdentity() = %d, want 5", got)
+	}
+}
+
+func TestAllNil(t *testing.T) {
+	if !allNil([]interface{}{nil, nil}) {
+		t.Fatalf("expected allNil([nil, nil]) to be true")
+	}
+	if allNil([]interface{}{nil, "x"}) {
+		t.Fatalf("expected allNil([nil, \"x\"]) to be false")
+	}
+}